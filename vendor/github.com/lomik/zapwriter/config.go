@@ -2,8 +2,11 @@ package zapwriter
 
 import (
 	"fmt"
+	"io"
 	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -11,11 +14,26 @@ import (
 
 type Config struct {
 	Logger           string `toml:"logger" json:"logger"`                       // handler name, default empty
-	File             string `toml:"file" json:"file"`                           // filename, "stderr", "stdout", "empty" (=="stderr"), "none"
+	File             string `toml:"file" json:"file"`                           // filename, "stderr", "stdout", "empty" (=="stderr"), "none", or kafka://broker1,broker2/topic?acks=all&compression=snappy
 	Level            string `toml:"level" json:"level"`                         // "debug", "info", "warn", "error", "dpanic", "panic", and "fatal"
 	Encoding         string `toml:"encoding" json:"encoding"`                   // "json", "console"
 	EncodingTime     string `toml:"encoding-time" json:"encoding-time"`         // "millis", "nanos", "epoch", "iso8601"
 	EncodingDuration string `toml:"encoding-duration" json:"encoding-duration"` // "seconds", "nanos", "string"
+
+	// Sampling, if set, thins out repetitive log lines the way zap's
+	// own sampler does: the first Initial entries per second for a
+	// given level+message pass through, then only every Thereafter-th.
+	Sampling *SamplingConfig `toml:"sampling" json:"sampling,omitempty"`
+	// Dedup, if set, suppresses entries identical in level+message+
+	// caller seen again within Window, replacing the run with a single
+	// periodic "repeated N times" summary.
+	Dedup *DedupConfig `toml:"dedup" json:"dedup,omitempty"`
+}
+
+// SamplingConfig mirrors zap's own sampling knobs.
+type SamplingConfig struct {
+	Initial    int `toml:"initial" json:"initial"`
+	Thereafter int `toml:"thereafter" json:"thereafter"`
 }
 
 func NewConfig() Config {
@@ -132,31 +150,55 @@ func (c *Config) encoder() (zapcore.Encoder, zap.AtomicLevel, error) {
 }
 
 func (c *Config) build(checkOnly bool) (*zap.Logger, error) {
+	logger, _, err := c.buildWithClosers(checkOnly)
+	return logger, err
+}
+
+// buildWithClosers is build, plus the io.Closers of any resources it
+// created (a kafka writer's producer, a dedup core's flush loop, ...)
+// so a caller that rebuilds loggers over time, like Manager, can shut
+// the previous generation down instead of leaking it.
+func (c *Config) buildWithClosers(checkOnly bool) (*zap.Logger, []io.Closer, error) {
 	u, err := url.Parse(c.File)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	encoder, atomicLevel, err := c.encoder()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if checkOnly {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	if strings.ToLower(u.Path) == "none" {
-		return zap.NewNop(), nil
+		return zap.NewNop(), nil, nil
 	}
 
 	ws, err := New(c.File)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	core := zapcore.NewCore(encoder, ws, atomicLevel)
+	var closers []io.Closer
+	if closer, ok := ws.(io.Closer); ok && ws != os.Stdout && ws != os.Stderr {
+		closers = append(closers, closer)
+	}
+
+	var core zapcore.Core = zapcore.NewCore(encoder, ws, atomicLevel)
+
+	if c.Sampling != nil {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, c.Sampling.Initial, c.Sampling.Thereafter)
+	}
+
+	if c.Dedup != nil {
+		dedup := newDedupCore(core, *c.Dedup)
+		core = dedup
+		closers = append(closers, dedup.(io.Closer))
+	}
 
-	return zap.New(core), nil
+	return zap.New(core), closers, nil
 }
@@ -0,0 +1,250 @@
+package zapwriter
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DedupConfig configures the dedup core wrapper installed by build()
+// when Config.Dedup is set.
+type DedupConfig struct {
+	// Window is how long repeated entries (same level+message+caller)
+	// are suppressed after the first one is written through.
+	Window time.Duration `toml:"window" json:"window"`
+	// SummaryEvery is how often the background flush loop checks for
+	// windows that have elapsed with suppressed entries still pending
+	// a "repeated N times" summary. Defaults to 10s.
+	SummaryEvery time.Duration `toml:"summary-every" json:"summary-every"`
+	// CacheSize bounds how many distinct keys are tracked at once so a
+	// storm of unique messages can't grow this wrapper unboundedly.
+	// Defaults to 4096.
+	CacheSize int `toml:"cache-size" json:"cache-size"`
+}
+
+const (
+	defaultDedupSummaryEvery = 10 * time.Second
+	defaultDedupCacheSize    = 4096
+)
+
+type dedupEntry struct {
+	key   uint64
+	entry zapcore.Entry
+	first time.Time
+	count int
+}
+
+// dedupCore wraps another zapcore.Core and drops repeated entries seen
+// again within Window, so a backend outage that would otherwise log
+// the same error millions of times a minute instead logs it once per
+// window plus a periodic summary of how many were suppressed.
+type dedupCore struct {
+	zapcore.Core
+
+	window       time.Duration
+	summaryEvery time.Duration
+	cacheSize    int
+
+	// mu guards entries/order, which are shared with any core returned
+	// by With so a storm logged through a child logger is deduped
+	// against the same state as its parent.
+	mu      *sync.Mutex
+	entries map[uint64]*list.Element
+	order   *list.List // front = most recently written
+
+	// stop terminates flushLoop. Only the dedupCore returned by
+	// newDedupCore owns it; clones made by With share entries/order but
+	// not stop, since only the original runs a flush loop to stop.
+	stop     chan struct{}
+	stopOnce *sync.Once
+}
+
+func newDedupCore(core zapcore.Core, cfg DedupConfig) zapcore.Core {
+	summaryEvery := cfg.SummaryEvery
+	if summaryEvery <= 0 {
+		summaryEvery = defaultDedupSummaryEvery
+	}
+	cacheSize := cfg.CacheSize
+	if cacheSize <= 0 {
+		cacheSize = defaultDedupCacheSize
+	}
+
+	d := &dedupCore{
+		Core:         core,
+		window:       cfg.Window,
+		summaryEvery: summaryEvery,
+		cacheSize:    cacheSize,
+		mu:           &sync.Mutex{},
+		entries:      make(map[uint64]*list.Element),
+		order:        list.New(),
+		stop:         make(chan struct{}),
+		stopOnce:     &sync.Once{},
+	}
+
+	go d.flushLoop()
+
+	return d
+}
+
+func dedupKey(ent zapcore.Entry) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(ent.Level.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(ent.Message))
+	h.Write([]byte{0})
+	h.Write([]byte(ent.Caller.String()))
+	return h.Sum64()
+}
+
+// Check delegates straight to the wrapped core's own Check once it has
+// decided ent isn't a suppressed duplicate, instead of adding itself to
+// ce and doing the real work in Write. That matters whenever the
+// wrapped core's Check does more than Enabled+AddCore -- e.g. a
+// zap.Sampler's thinning logic lives entirely in Check, and Write is a
+// pure passthrough -- so calling d.Core.Write directly would silently
+// skip it.
+func (d *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !d.Core.Enabled(ent.Level) {
+		return ce
+	}
+	ok, suppressed := d.allow(ent)
+	if suppressed > 0 {
+		d.writeSummary(ent, suppressed)
+	}
+	if !ok {
+		return ce
+	}
+	return d.Core.Check(ent, ce)
+}
+
+// Write supports callers that write through a dedupCore directly
+// without going through Check first (Check is the path zap's own
+// loggers use), so it runs the same dedup decision and then re-enters
+// the wrapped core via Check rather than calling its Write directly,
+// for the same reason Check delegates instead of calling Write itself.
+func (d *dedupCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ok, suppressed := d.allow(ent)
+	if suppressed > 0 {
+		d.writeSummary(ent, suppressed)
+	}
+	if !ok {
+		return nil
+	}
+	if ce := d.Core.Check(ent, nil); ce != nil {
+		return ce.Write(fields)
+	}
+	return nil
+}
+
+// allow runs the dedup bookkeeping for ent: it reports whether ent
+// should be let through this time, and, if a previous window had
+// entries suppressed and just elapsed, how many of those there were so
+// the caller can emit a "repeated N times" summary immediately instead
+// of waiting on flushLoop's ticker to notice.
+func (d *dedupCore) allow(ent zapcore.Entry) (ok bool, suppressed int) {
+	key := dedupKey(ent)
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	el, found := d.entries[key]
+	if !found {
+		e := &dedupEntry{key: key, entry: ent, first: now}
+		d.entries[key] = d.order.PushFront(e)
+		d.evictLocked()
+		return true, 0
+	}
+
+	e := el.Value.(*dedupEntry)
+	d.order.MoveToFront(el)
+	if now.Sub(e.first) < d.window {
+		e.count++
+		return false, 0
+	}
+
+	suppressed = e.count
+	e.first = now
+	e.count = 0
+	return true, suppressed
+}
+
+func (d *dedupCore) writeSummary(ent zapcore.Entry, count int) {
+	summary := ent
+	summary.Message = fmt.Sprintf("%s (repeated %d times)", ent.Message, count)
+	d.Core.Write(summary, nil)
+}
+
+func (d *dedupCore) evictLocked() {
+	for len(d.entries) > d.cacheSize {
+		oldest := d.order.Back()
+		if oldest == nil {
+			return
+		}
+		delete(d.entries, oldest.Value.(*dedupEntry).key)
+		d.order.Remove(oldest)
+	}
+}
+
+// flushLoop is the backstop for keys that go idle: allow already emits
+// the summary itself the moment a busy key's next occurrence notices
+// its window has elapsed, but a key that simply stops recurring would
+// otherwise never trigger that check again. flushLoop periodically
+// sweeps for exactly that case so a suppressed count isn't lost just
+// because nothing identical ever arrives again.
+func (d *dedupCore) flushLoop() {
+	ticker := time.NewTicker(d.summaryEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+
+		var due []*dedupEntry
+
+		d.mu.Lock()
+		now := time.Now()
+		for _, el := range d.entries {
+			e := el.Value.(*dedupEntry)
+			if e.count > 0 && now.Sub(e.first) >= d.window {
+				due = append(due, &dedupEntry{key: e.key, entry: e.entry, count: e.count})
+				e.count = 0
+				e.first = now
+			}
+		}
+		d.mu.Unlock()
+
+		for _, e := range due {
+			d.writeSummary(e.entry, e.count)
+		}
+	}
+}
+
+// Close stops the background flush loop and is safe to call more than
+// once. It only has an effect on the dedupCore returned by
+// newDedupCore; clones made by With don't run their own flush loop.
+func (d *dedupCore) Close() error {
+	if d.stop != nil {
+		d.stopOnce.Do(func() { close(d.stop) })
+	}
+	return nil
+}
+
+func (d *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{
+		Core:         d.Core.With(fields),
+		window:       d.window,
+		summaryEvery: d.summaryEvery,
+		cacheSize:    d.cacheSize,
+		mu:           d.mu,
+		entries:      d.entries,
+		order:        d.order,
+	}
+}
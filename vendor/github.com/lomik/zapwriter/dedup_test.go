@@ -0,0 +1,151 @@
+package zapwriter
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type countingCore struct {
+	zapcore.Core
+	writes []string
+}
+
+func (c *countingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.writes = append(c.writes, ent.Message)
+	return nil
+}
+
+func (c *countingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func TestDedupCoreSuppressesWithinWindow(t *testing.T) {
+	inner := &countingCore{Core: zapcore.NewNopCore()}
+	core := newDedupCore(inner, DedupConfig{Window: time.Hour}).(*dedupCore)
+	defer core.Close()
+
+	ent := zapcore.Entry{Level: zapcore.ErrorLevel, Message: "backend unreachable"}
+
+	for i := 0; i < 5; i++ {
+		if err := core.Write(ent, nil); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	if len(inner.writes) != 1 {
+		t.Fatalf("inner core got %d writes, want 1 (the rest should be suppressed within the window)", len(inner.writes))
+	}
+}
+
+func TestDedupCoreEmitsSummaryOnceWindowElapses(t *testing.T) {
+	inner := &countingCore{Core: zapcore.NewNopCore()}
+	core := newDedupCore(inner, DedupConfig{Window: time.Millisecond, SummaryEvery: 5 * time.Millisecond}).(*dedupCore)
+	defer core.Close()
+
+	ent := zapcore.Entry{Level: zapcore.ErrorLevel, Message: "backend unreachable"}
+
+	if err := core.Write(ent, nil); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := core.Write(ent, nil); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	deadline := time.After(time.Second)
+	for {
+		if len(inner.writes) >= 2 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("got %d writes after 1s, want at least 2 (first entry + repeated-times summary)", len(inner.writes))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if got := inner.writes[1]; got == ent.Message {
+		t.Fatalf("second write message = %q, want it to include a repeated-times summary", got)
+	}
+}
+
+// checkingCore's Check does real work (like a sampler's) instead of
+// just adding itself, so a dedupCore that bypassed d.Core.Check in
+// favor of calling d.Core.Write directly would never see it run.
+type checkingCore struct {
+	zapcore.Core
+	checked int
+	writes  []string
+}
+
+func (c *checkingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	c.checked++
+	return ce.AddCore(ent, c)
+}
+
+func (c *checkingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.writes = append(c.writes, ent.Message)
+	return nil
+}
+
+func TestDedupCoreDelegatesCheckToWrappedCore(t *testing.T) {
+	inner := &checkingCore{Core: zapcore.NewNopCore()}
+	core := newDedupCore(inner, DedupConfig{Window: time.Hour}).(*dedupCore)
+	defer core.Close()
+
+	ent := zapcore.Entry{Level: zapcore.ErrorLevel, Message: "backend unreachable"}
+
+	if err := core.Write(ent, nil); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if inner.checked != 1 {
+		t.Fatalf("wrapped core's Check was called %d times, want 1 -- dedup must delegate through Check, not call Write directly", inner.checked)
+	}
+	if len(inner.writes) != 1 {
+		t.Fatalf("wrapped core got %d writes, want 1", len(inner.writes))
+	}
+}
+
+func TestDedupCoreEmitsSummaryAsSoonAsWindowElapses(t *testing.T) {
+	inner := &countingCore{Core: zapcore.NewNopCore()}
+	// SummaryEvery is long enough that, if the summary only ever came
+	// from flushLoop's ticker, this test would time out -- it must come
+	// from Write itself noticing the elapsed window.
+	core := newDedupCore(inner, DedupConfig{Window: 5 * time.Millisecond, SummaryEvery: time.Hour}).(*dedupCore)
+	defer core.Close()
+
+	ent := zapcore.Entry{Level: zapcore.ErrorLevel, Message: "backend unreachable"}
+
+	if err := core.Write(ent, nil); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := core.Write(ent, nil); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if err := core.Write(ent, nil); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if len(inner.writes) != 3 {
+		t.Fatalf("inner core got %d writes, want 3 (first entry + summary emitted by the elapsed-window write, without waiting on the ticker + that write itself opening the next window)", len(inner.writes))
+	}
+	if got := inner.writes[1]; got == ent.Message {
+		t.Fatalf("second write message = %q, want it to include a repeated-times summary", got)
+	}
+}
+
+func TestDedupCoreCloseIsIdempotent(t *testing.T) {
+	core := newDedupCore(zapcore.NewNopCore(), DedupConfig{Window: time.Second}).(*dedupCore)
+
+	if err := core.Close(); err != nil {
+		t.Fatalf("first Close() error = %v", err)
+	}
+	if err := core.Close(); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+}
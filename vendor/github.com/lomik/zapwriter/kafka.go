@@ -0,0 +1,234 @@
+package zapwriter
+
+import (
+	"expvar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+const (
+	kafkaDefaultFlushInterval = time.Second
+	kafkaDefaultFlushBytes    = 1 << 20 // 1MiB
+	kafkaDefaultQueueSize     = 1 << 16 // entries
+)
+
+var (
+	kafkaQueued  = expvar.NewInt("zapwriter_kafka_queued")
+	kafkaDropped = expvar.NewInt("zapwriter_kafka_dropped")
+)
+
+// kafkaWriter is a zapcore.WriteSyncer that batches already-encoded log
+// entries and ships them to a Kafka topic on a background goroutine. It
+// never blocks the caller: once the local queue is full, new entries are
+// dropped and counted in kafkaDropped rather than stalling the writer
+// (and, transitively, request handlers) on a slow or unreachable broker.
+type kafkaWriter struct {
+	topic    string
+	producer sarama.AsyncProducer
+
+	flushInterval time.Duration
+	flushBytes    int
+
+	queue chan []byte
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// kafkaOptions is the result of parsing a kafka:// URL, kept separate
+// from newKafkaWriter so the parsing logic can be unit tested without
+// dialing a real broker.
+type kafkaOptions struct {
+	brokers       []string
+	topic         string
+	requiredAcks  sarama.RequiredAcks
+	compression   sarama.CompressionCodec
+	flushInterval time.Duration
+	flushBytes    int
+	queueSize     int
+}
+
+func parseKafkaURL(u *url.URL) (kafkaOptions, error) {
+	opts := kafkaOptions{
+		brokers:       strings.Split(u.Host, ","),
+		topic:         strings.Trim(u.Path, "/"),
+		requiredAcks:  sarama.WaitForLocal,
+		compression:   sarama.CompressionNone,
+		flushInterval: kafkaDefaultFlushInterval,
+		flushBytes:    kafkaDefaultFlushBytes,
+		queueSize:     kafkaDefaultQueueSize,
+	}
+
+	q := u.Query()
+
+	switch strings.ToLower(q.Get("acks")) {
+	case "all", "-1":
+		opts.requiredAcks = sarama.WaitForAll
+	case "none", "0":
+		opts.requiredAcks = sarama.NoResponse
+	}
+
+	switch strings.ToLower(q.Get("compression")) {
+	case "snappy":
+		opts.compression = sarama.CompressionSnappy
+	case "gzip":
+		opts.compression = sarama.CompressionGZIP
+	case "lz4":
+		opts.compression = sarama.CompressionLZ4
+	}
+
+	if v := q.Get("flush_interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return kafkaOptions{}, err
+		}
+		opts.flushInterval = d
+	}
+
+	if v := q.Get("flush_bytes"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return kafkaOptions{}, err
+		}
+		opts.flushBytes = n
+	}
+
+	if v := q.Get("queue_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return kafkaOptions{}, err
+		}
+		opts.queueSize = n
+	}
+
+	return opts, nil
+}
+
+// newKafkaWriter parses a kafka://broker1,broker2/topic?acks=all&compression=snappy
+// URL into a connected, already-running kafkaWriter.
+func newKafkaWriter(u *url.URL) (*kafkaWriter, error) {
+	opts, err := parseKafkaURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = opts.requiredAcks
+	config.Producer.Compression = opts.compression
+
+	producer, err := sarama.NewAsyncProducer(opts.brokers, config)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &kafkaWriter{
+		topic:         opts.topic,
+		producer:      producer,
+		flushInterval: opts.flushInterval,
+		flushBytes:    opts.flushBytes,
+		queue:         make(chan []byte, opts.queueSize),
+		stop:          make(chan struct{}),
+	}
+
+	w.wg.Add(2)
+	go w.loop()
+	go w.drainErrors()
+
+	return w, nil
+}
+
+// Write implements zapcore.WriteSyncer. It copies p (zap reuses its
+// buffers) onto the queue and returns immediately; it never waits on
+// Kafka.
+func (w *kafkaWriter) Write(p []byte) (int, error) {
+	entry := make([]byte, len(p))
+	copy(entry, p)
+
+	select {
+	case w.queue <- entry:
+		kafkaQueued.Add(1)
+	default:
+		kafkaDropped.Add(1)
+	}
+
+	return len(p), nil
+}
+
+// Sync is a no-op: entries are flushed on their own interval/size
+// threshold so a blocking Sync can't stall the caller either.
+func (w *kafkaWriter) Sync() error {
+	return nil
+}
+
+func (w *kafkaWriter) loop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	var batch [][]byte
+	batchBytes := 0
+
+	// send hands the batch to sarama's async producer. Each entry is
+	// pushed with a select against w.stop so a broker that's backed up
+	// long enough to fill sarama's own input buffering can't wedge this
+	// goroutine forever -- that would also stop draining w.queue and
+	// turn "drop when full" into "drop everything", and would make
+	// Close hang forever waiting on w.wg.
+	send := func() {
+		for i, entry := range batch {
+			select {
+			case w.producer.Input() <- &sarama.ProducerMessage{
+				Topic: w.topic,
+				Value: sarama.ByteEncoder(entry),
+			}:
+			case <-w.stop:
+				kafkaDropped.Add(int64(len(batch) - i))
+				batch = batch[:0]
+				batchBytes = 0
+				return
+			}
+		}
+		batch = batch[:0]
+		batchBytes = 0
+	}
+
+	for {
+		select {
+		case entry := <-w.queue:
+			kafkaQueued.Add(-1)
+			batch = append(batch, entry)
+			batchBytes += len(entry)
+			if batchBytes >= w.flushBytes {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case <-w.stop:
+			send()
+			w.producer.AsyncClose()
+			return
+		}
+	}
+}
+
+func (w *kafkaWriter) drainErrors() {
+	defer w.wg.Done()
+
+	for range w.producer.Errors() {
+		kafkaDropped.Add(1)
+	}
+}
+
+// Close stops the background flush loop and closes the underlying
+// producer. It is not part of zapcore.WriteSyncer but is exposed for
+// callers that want a clean shutdown (e.g. on SIGHUP reload).
+func (w *kafkaWriter) Close() error {
+	close(w.stop)
+	w.wg.Wait()
+	return nil
+}
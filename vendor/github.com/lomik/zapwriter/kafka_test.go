@@ -0,0 +1,69 @@
+package zapwriter
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestParseKafkaURL(t *testing.T) {
+	u, err := url.Parse("kafka://broker1:9092,broker2:9092/my-topic?acks=all&compression=snappy&flush_interval=2s&flush_bytes=1024&queue_size=8")
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	opts, err := parseKafkaURL(u)
+	if err != nil {
+		t.Fatalf("parseKafkaURL() error = %v", err)
+	}
+
+	wantBrokers := []string{"broker1:9092", "broker2:9092"}
+	if len(opts.brokers) != len(wantBrokers) || opts.brokers[0] != wantBrokers[0] || opts.brokers[1] != wantBrokers[1] {
+		t.Errorf("brokers = %v, want %v", opts.brokers, wantBrokers)
+	}
+	if opts.topic != "my-topic" {
+		t.Errorf("topic = %q, want my-topic", opts.topic)
+	}
+	if opts.requiredAcks != sarama.WaitForAll {
+		t.Errorf("requiredAcks = %v, want WaitForAll", opts.requiredAcks)
+	}
+	if opts.compression != sarama.CompressionSnappy {
+		t.Errorf("compression = %v, want CompressionSnappy", opts.compression)
+	}
+	if opts.flushInterval != 2*time.Second {
+		t.Errorf("flushInterval = %v, want 2s", opts.flushInterval)
+	}
+	if opts.flushBytes != 1024 {
+		t.Errorf("flushBytes = %d, want 1024", opts.flushBytes)
+	}
+	if opts.queueSize != 8 {
+		t.Errorf("queueSize = %d, want 8", opts.queueSize)
+	}
+}
+
+func TestParseKafkaURLDefaults(t *testing.T) {
+	u, err := url.Parse("kafka://broker1:9092/my-topic")
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	opts, err := parseKafkaURL(u)
+	if err != nil {
+		t.Fatalf("parseKafkaURL() error = %v", err)
+	}
+
+	if opts.requiredAcks != sarama.WaitForLocal {
+		t.Errorf("requiredAcks = %v, want the default WaitForLocal", opts.requiredAcks)
+	}
+	if opts.flushInterval != kafkaDefaultFlushInterval {
+		t.Errorf("flushInterval = %v, want default %v", opts.flushInterval, kafkaDefaultFlushInterval)
+	}
+	if opts.flushBytes != kafkaDefaultFlushBytes {
+		t.Errorf("flushBytes = %d, want default %d", opts.flushBytes, kafkaDefaultFlushBytes)
+	}
+	if opts.queueSize != kafkaDefaultQueueSize {
+		t.Errorf("queueSize = %d, want default %d", opts.queueSize, kafkaDefaultQueueSize)
+	}
+}
@@ -0,0 +1,54 @@
+package zapwriter
+
+import "testing"
+
+func TestManagerNamedLongestPrefix(t *testing.T) {
+	m, err := NewManager([]Config{
+		{File: "none", Level: "info"},
+		{Logger: "zipper.backend", File: "none", Level: "info"},
+		{Logger: "zipper.backend.mycluster", File: "none", Level: "info"},
+	})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	if got := len(m.aliases); got != 2 {
+		t.Fatalf("len(aliases) = %d, want 2", got)
+	}
+	if m.aliases[0] != "zipper.backend.mycluster" {
+		t.Fatalf("aliases[0] = %q, want the longer alias sorted first", m.aliases[0])
+	}
+
+	// These just need to not panic and to resolve without error; the
+	// interesting assertion is that build() picked the most specific
+	// alias, which we already checked via m.aliases above.
+	m.Named("zipper.backend.mycluster.worker")
+	m.Named("zipper.backend.othercluster")
+	m.Named("unrelated")
+}
+
+func TestManagerReloadClosesPreviousGeneration(t *testing.T) {
+	m, err := NewManager([]Config{
+		{File: "none", Level: "info"},
+		{Logger: "a", File: "stderr", Level: "info", Dedup: &DedupConfig{Window: 0}},
+	})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	first := m.closers
+	if len(first) != 1 {
+		t.Fatalf("len(closers) = %d, want 1 dedup closer", len(first))
+	}
+
+	if err := m.Reload([]Config{{File: "none", Level: "info"}}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	// The dedup core from the first generation should have had its
+	// flush loop stopped; closing it again must be a no-op, not a
+	// "close of closed channel" panic.
+	if err := first[0].Close(); err != nil {
+		t.Fatalf("second Close() of previous generation's closer returned an error: %v", err)
+	}
+}
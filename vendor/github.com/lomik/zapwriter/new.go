@@ -0,0 +1,47 @@
+package zapwriter
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// New returns the zapcore.WriteSyncer for file. Besides plain paths it
+// understands the pseudo-targets "stdout"/"stderr"/"none" and, starting
+// with the kafka:// scheme, remote sinks that are backed by their own
+// goroutines rather than an *os.File.
+func New(file string) (zapcore.WriteSyncer, error) {
+	u, err := url.Parse(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "kafka":
+		return newKafkaWriter(u)
+	}
+
+	switch strings.ToLower(file) {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr", "", "empty":
+		return os.Stderr, nil
+	case "none":
+		return nopWriteSyncer{}, nil
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("can't open file %#v: %s", file, err.Error())
+	}
+
+	return f, nil
+}
+
+type nopWriteSyncer struct{}
+
+func (nopWriteSyncer) Write(p []byte) (int, error) { return len(p), nil }
+func (nopWriteSyncer) Sync() error                 { return nil }
@@ -0,0 +1,97 @@
+package helper
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SetRequestDeadline bounds the whole DoQuery call (every attempt and
+// retry) independently of whatever deadline the caller's ctx carries.
+// A zero time.Time clears it.
+func (c *HttpQuery) SetRequestDeadline(t time.Time) {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.requestCancelCh = resetDeadline(c.requestTimer, t)
+	if t.IsZero() {
+		c.requestTimer = nil
+	} else {
+		c.requestTimer = time.AfterFunc(time.Until(t), closer(c.requestCancelCh))
+	}
+}
+
+// SetAttemptDeadline cancels the backend attempt running inside
+// doRequest when t passes, independently of SetRequestDeadline and the
+// caller's ctx. t is one absolute time shared by every attempt: it is
+// not re-armed per attempt, so once it has passed each later attempt is
+// cancelled as soon as it starts, until the deadline is set again. A
+// zero time.Time clears it.
+func (c *HttpQuery) SetAttemptDeadline(t time.Time) {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.attemptCancelCh = resetDeadline(c.attemptTimer, t)
+	if t.IsZero() {
+		c.attemptTimer = nil
+	} else {
+		c.attemptTimer = time.AfterFunc(time.Until(t), closer(c.attemptCancelCh))
+	}
+}
+
+// resetDeadline stops the previous timer (its cancel channel is either
+// already closed, if the timer had already fired, or simply discarded
+// otherwise) and returns a fresh cancel channel for the next timer, or
+// nil if t clears the deadline.
+func resetDeadline(prev *time.Timer, t time.Time) chan struct{} {
+	if prev != nil {
+		prev.Stop()
+	}
+
+	if t.IsZero() {
+		return nil
+	}
+
+	return make(chan struct{})
+}
+
+func closer(ch chan struct{}) func() {
+	return func() {
+		close(ch)
+	}
+}
+
+// withDeadlines returns a context that is canceled when parent is
+// done, when the current request deadline fires, or when the current
+// attempt deadline fires, whichever happens first.
+func (c *HttpQuery) withDeadlines(parent context.Context) (context.Context, context.CancelFunc) {
+	c.deadlineMu.Lock()
+	requestCh := c.requestCancelCh
+	attemptCh := c.attemptCancelCh
+	c.deadlineMu.Unlock()
+
+	if requestCh == nil && attemptCh == nil {
+		return parent, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-requestCh: // nil channel blocks forever, never selected
+			cancel()
+		case <-attemptCh:
+			cancel()
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// deadlines holds the mutex-guarded state backing SetRequestDeadline
+// and SetAttemptDeadline. It is embedded in HttpQuery.
+type deadlines struct {
+	deadlineMu      sync.Mutex
+	requestCancelCh chan struct{}
+	requestTimer    *time.Timer
+	attemptCancelCh chan struct{}
+	attemptTimer    *time.Timer
+}
@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAttemptDeadlineCancelsContext(t *testing.T) {
+	var c HttpQuery
+	c.SetAttemptDeadline(time.Now().Add(20 * time.Millisecond))
+
+	ctx, cancel := c.withDeadlines(context.Background())
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context was never cancelled by the attempt deadline")
+	}
+}
+
+func TestSetDeadlineZeroClears(t *testing.T) {
+	var c HttpQuery
+	c.SetRequestDeadline(time.Now().Add(time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	c.SetRequestDeadline(time.Time{})
+
+	ctx, cancel := c.withDeadlines(context.Background())
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("context was cancelled even though the deadline was cleared")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSetDeadlineReplacesPreviousTimer(t *testing.T) {
+	var c HttpQuery
+	c.SetRequestDeadline(time.Now().Add(time.Hour))
+	c.SetRequestDeadline(time.Now().Add(20 * time.Millisecond))
+
+	ctx, cancel := c.withDeadlines(context.Background())
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context was never cancelled by the replacement, shorter deadline")
+	}
+}
@@ -0,0 +1,291 @@
+package helper
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ServerPicker chooses which backend a query attempt should go to and
+// is told how each attempt turned out, so it can steer future picks
+// away from servers that are failing or slow.
+type ServerPicker interface {
+	// Pick returns a server to try, skipping anything in exclude. When
+	// exclude covers every server, it returns one of them anyway.
+	Pick(exclude map[string]bool) string
+	ReportSuccess(server string, latency time.Duration)
+	ReportError(server string)
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+const (
+	errorWindowSize   = 20
+	breakerTripErrors = 3
+	initialCooldown   = 5 * time.Second
+	maxCooldown       = 2 * time.Minute
+	latencyEWMAAlpha  = 0.2
+)
+
+// serverHealth tracks the rolling error rate, EWMA latency, in-flight
+// count and circuit breaker state for a single backend.
+type serverHealth struct {
+	inFlight int64 // atomic
+
+	mu          sync.Mutex
+	state       breakerState
+	cooldown    time.Duration
+	openUntil   time.Time
+	consecutive int
+	ewmaLatency float64
+
+	errors   [errorWindowSize]bool
+	errorsN  int
+	errorsAt int
+}
+
+func newServerHealth() *serverHealth {
+	return &serverHealth{cooldown: initialCooldown}
+}
+
+// allow reports whether a new attempt may be sent to this server right
+// now: always true when closed, never while open (until the cooldown
+// elapses, which transitions it to half-open), and at most once at a
+// time while half-open.
+func (h *serverHealth) allow() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch h.state {
+	case breakerOpen:
+		if time.Now().Before(h.openUntil) {
+			return false
+		}
+		h.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		return atomic.LoadInt64(&h.inFlight) == 0
+	default:
+		return true
+	}
+}
+
+func (h *serverHealth) errorRate() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.errorsN == 0 {
+		return 0
+	}
+	failed := 0
+	for i := 0; i < h.errorsN; i++ {
+		if h.errors[i] {
+			failed++
+		}
+	}
+	return float64(failed) / float64(h.errorsN)
+}
+
+func (h *serverHealth) recordOutcome(failed bool) {
+	h.errors[h.errorsAt] = failed
+	h.errorsAt = (h.errorsAt + 1) % len(h.errors)
+	if h.errorsN < len(h.errors) {
+		h.errorsN++
+	}
+}
+
+func (h *serverHealth) reportSuccess(latency time.Duration) {
+	h.mu.Lock()
+	h.recordOutcome(false)
+	h.consecutive = 0
+	h.state = breakerClosed
+	h.cooldown = initialCooldown
+	if h.ewmaLatency == 0 {
+		h.ewmaLatency = float64(latency)
+	} else {
+		h.ewmaLatency = latencyEWMAAlpha*float64(latency) + (1-latencyEWMAAlpha)*h.ewmaLatency
+	}
+	h.mu.Unlock()
+}
+
+func (h *serverHealth) reportError() {
+	h.mu.Lock()
+	h.recordOutcome(true)
+	h.consecutive++
+	if h.state == breakerHalfOpen || h.consecutive >= breakerTripErrors {
+		h.state = breakerOpen
+		h.openUntil = time.Now().Add(h.cooldown)
+		h.cooldown *= 2
+		if h.cooldown > maxCooldown {
+			h.cooldown = maxCooldown
+		}
+	}
+	h.mu.Unlock()
+}
+
+// releaseInFlight decrements the in-flight count without letting it
+// drop below zero when an outcome is reported for an attempt that was
+// never counted by Pick.
+func (h *serverHealth) releaseInFlight() {
+	for {
+		n := atomic.LoadInt64(&h.inFlight)
+		if n <= 0 || atomic.CompareAndSwapInt64(&h.inFlight, n, n-1) {
+			return
+		}
+	}
+}
+
+func (h *serverHealth) snapshot() map[string]interface{} {
+	errorRate := h.errorRate()
+	h.mu.Lock()
+	state := h.state.String()
+	latency := h.ewmaLatency
+	h.mu.Unlock()
+
+	return map[string]interface{}{
+		"state":           state,
+		"error_rate":      errorRate,
+		"ewma_latency_ms": latency / float64(time.Millisecond),
+		"in_flight":       atomic.LoadInt64(&h.inFlight),
+	}
+}
+
+// CircuitBreakerPicker is the default ServerPicker. It keeps each
+// server closed/half-open/open per the usual circuit breaker states
+// and, among the servers currently allowed to take traffic, picks with
+// power-of-two-choices: sample two candidates and send the request to
+// whichever has fewer in-flight requests (ties broken by lower EWMA
+// latency).
+type CircuitBreakerPicker struct {
+	servers []string
+	health  map[string]*serverHealth
+}
+
+func NewCircuitBreakerPicker(servers []string) *CircuitBreakerPicker {
+	p := &CircuitBreakerPicker{
+		servers: servers,
+		health:  make(map[string]*serverHealth, len(servers)),
+	}
+	for _, s := range servers {
+		p.health[s] = newServerHealth()
+	}
+	return p
+}
+
+func (p *CircuitBreakerPicker) Pick(exclude map[string]bool) string {
+	candidates := make([]string, 0, len(p.servers))
+	for _, s := range p.servers {
+		if exclude[s] {
+			continue
+		}
+		if p.health[s].allow() {
+			candidates = append(candidates, s)
+		}
+	}
+
+	if len(candidates) == 0 {
+		// Every server is either excluded or tripped. Fall back to a
+		// random non-excluded one, ignoring health state, so callers
+		// always get a server back without every attempt past the
+		// first lap piling onto a single fixed server.
+		nonExcluded := make([]string, 0, len(p.servers))
+		for _, s := range p.servers {
+			if !exclude[s] {
+				nonExcluded = append(nonExcluded, s)
+			}
+		}
+		if len(nonExcluded) == 0 {
+			// exclude covers every server; there's nothing left that
+			// hasn't already been tried, so hand back the full list.
+			nonExcluded = p.servers
+		}
+
+		srv := nonExcluded[rand.Intn(len(nonExcluded))]
+		atomic.AddInt64(&p.health[srv].inFlight, 1)
+		return srv
+	}
+
+	srv := candidates[rand.Intn(len(candidates))]
+	if len(candidates) > 1 {
+		other := candidates[rand.Intn(len(candidates))]
+		srv = p.lessLoaded(srv, other)
+	}
+
+	atomic.AddInt64(&p.health[srv].inFlight, 1)
+	return srv
+}
+
+func (p *CircuitBreakerPicker) lessLoaded(a, b string) string {
+	aInFlight := atomic.LoadInt64(&p.health[a].inFlight)
+	bInFlight := atomic.LoadInt64(&p.health[b].inFlight)
+	if aInFlight != bInFlight {
+		if aInFlight < bInFlight {
+			return a
+		}
+		return b
+	}
+
+	p.health[a].mu.Lock()
+	aLatency := p.health[a].ewmaLatency
+	p.health[a].mu.Unlock()
+
+	p.health[b].mu.Lock()
+	bLatency := p.health[b].ewmaLatency
+	p.health[b].mu.Unlock()
+
+	if aLatency <= bLatency {
+		return a
+	}
+	return b
+}
+
+func (p *CircuitBreakerPicker) ReportSuccess(server string, latency time.Duration) {
+	h, ok := p.health[server]
+	if !ok {
+		return
+	}
+	h.releaseInFlight()
+	h.reportSuccess(latency)
+}
+
+func (p *CircuitBreakerPicker) ReportError(server string) {
+	h, ok := p.health[server]
+	if !ok {
+		return
+	}
+	h.releaseInFlight()
+	h.reportError()
+}
+
+// ServeHTTP exposes per-server breaker state as JSON, meant to be
+// mounted at a path like /debug/backends so operators can see which
+// backends are currently shorted.
+func (p *CircuitBreakerPicker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	out := make(map[string]interface{}, len(p.servers))
+	for _, s := range p.servers {
+		out[s] = p.health[s].snapshot()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,70 @@
+package helper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerPickerStateTransitions(t *testing.T) {
+	p := NewCircuitBreakerPicker([]string{"server1"})
+
+	for i := 0; i < breakerTripErrors; i++ {
+		srv := p.Pick(nil)
+		if srv != "server1" {
+			t.Fatalf("Pick() = %q, want server1", srv)
+		}
+		p.ReportError(srv)
+	}
+
+	if p.health["server1"].allow() {
+		t.Fatal("breaker should be open after consecutive failures, but allow() returned true")
+	}
+
+	p.health["server1"].openUntil = time.Now().Add(-time.Millisecond)
+	if !p.health["server1"].allow() {
+		t.Fatal("breaker should transition to half-open once the cooldown has elapsed")
+	}
+
+	srv := p.Pick(nil)
+	p.ReportSuccess(srv, time.Millisecond)
+	if p.health["server1"].state != breakerClosed {
+		t.Fatalf("breaker state = %v, want closed after a successful half-open probe", p.health["server1"].state)
+	}
+}
+
+func TestCircuitBreakerPickerFallbackHonorsExclude(t *testing.T) {
+	p := NewCircuitBreakerPicker([]string{"server1", "server2"})
+
+	// Trip every server open so Pick must use the fallback branch.
+	for _, srv := range []string{"server1", "server2"} {
+		for i := 0; i < breakerTripErrors; i++ {
+			p.ReportError(srv)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		srv := p.Pick(map[string]bool{"server1": true})
+		p.ReportError(srv)
+		if srv != "server2" {
+			t.Fatalf("Pick(exclude server1) = %q, want server2 even though every server has tripped", srv)
+		}
+	}
+}
+
+func TestCircuitBreakerPickerInFlightNeverGoesNegative(t *testing.T) {
+	p := NewCircuitBreakerPicker([]string{"server1", "server2"})
+
+	// Trip every server open so Pick must use the fallback branch.
+	for _, srv := range []string{"server1", "server2"} {
+		for i := 0; i < breakerTripErrors; i++ {
+			p.ReportError(srv)
+		}
+	}
+
+	srv := p.Pick(nil)
+	p.ReportError(srv)
+
+	if inFlight := p.health[srv].inFlight; inFlight != 0 {
+		t.Fatalf("inFlight = %d after a single Pick+ReportError via the fallback branch, want 0", inFlight)
+	}
+}
@@ -8,7 +8,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"sync/atomic"
+	"sync"
+	"time"
 
 	"github.com/go-graphite/carbonzipper/limiter"
 	cu "github.com/go-graphite/carbonzipper/util/apictx"
@@ -31,8 +32,17 @@ type HttpQuery struct {
 	limiter   limiter.ServerLimiter
 	client    *http.Client
 	encoding  string
-
-	counter uint64
+	picker    ServerPicker
+	deadlines
+
+	// HedgeAfter is how long DoQuery waits for the first attempt before
+	// firing a speculative request against a different server. Zero
+	// disables hedging and keeps the original strictly sequential
+	// behavior.
+	HedgeAfter time.Duration
+	// MaxConcurrent caps how many copies of a query can be in flight at
+	// once when hedging. Zero means "up to maxTries".
+	MaxConcurrent int
 }
 
 func NewHttpQuery(logger *zap.Logger, groupName string, servers []string, maxTries int, limiter limiter.ServerLimiter, client *http.Client, encoding string) *HttpQuery {
@@ -44,29 +54,20 @@ func NewHttpQuery(logger *zap.Logger, groupName string, servers []string, maxTri
 		limiter:   limiter,
 		client:    client,
 		encoding:  encoding,
+		picker:    NewCircuitBreakerPicker(servers),
 	}
 }
 
-func (c *HttpQuery) pickServer() string {
-	if len(c.servers) == 1 {
-		// No need to do heavy operations here
-		return c.servers[0]
-	}
-	logger := c.logger.With(zap.String("function", "picker"))
-	counter := atomic.AddUint64(&(c.counter), 1)
-	idx := counter % uint64(len(c.servers))
-	srv := c.servers[int(idx)]
-	logger.Debug("picked",
-		zap.Uint64("counter", counter),
-		zap.Uint64("idx", idx),
-		zap.String("Server", srv),
-	)
-
-	return srv
+// SetServerPicker overrides the default circuit-breaker picker, e.g. in
+// tests or for a deployment that wants a different backend selection
+// strategy.
+func (c *HttpQuery) SetServerPicker(picker ServerPicker) {
+	c.picker = picker
 }
 
-func (c *HttpQuery) doRequest(ctx context.Context, uri string, body []byte) (*ServerResponse, error) {
-	server := c.pickServer()
+func (c *HttpQuery) doRequest(ctx context.Context, server string, uri string, body []byte) (*ServerResponse, error) {
+	ctx, cancel := c.withDeadlines(ctx)
+	defer cancel()
 
 	u, err := url.Parse(server + uri)
 	if err != nil {
@@ -137,10 +138,25 @@ func (c *HttpQuery) DoQuery(ctx context.Context, uri string, body []byte) (*Serv
 		maxTries = len(c.servers)
 	}
 
+	if c.HedgeAfter <= 0 {
+		return c.doQuerySequential(ctx, uri, body, maxTries)
+	}
+
+	return c.doQueryHedged(ctx, uri, body, maxTries)
+}
+
+// doQuerySequential is the original strictly-sequential retry loop: one
+// attempt at a time, skipping servers already tried by an earlier try.
+func (c *HttpQuery) doQuerySequential(ctx context.Context, uri string, body []byte, maxTries int) (*ServerResponse, *errors.Errors) {
 	var e errors.Errors
+	tried := make(map[string]bool, maxTries)
 	for try := 0; try < maxTries; try++ {
-		res, err := c.doRequest(ctx, uri, body)
+		server := c.picker.Pick(tried)
+		tried[server] = true
+		start := time.Now()
+		res, err := c.doRequest(ctx, server, uri, body)
 		if err != nil {
+			c.picker.ReportError(server)
 			e.Add(err)
 			if ctx.Err() != nil {
 				return nil, &e
@@ -148,9 +164,85 @@ func (c *HttpQuery) DoQuery(ctx context.Context, uri string, body []byte) (*Serv
 			continue
 		}
 
+		c.picker.ReportSuccess(server, time.Since(start))
 		return res, nil
 	}
 
 	e.Add(types.ErrMaxTriesExceeded)
 	return nil, &e
 }
+
+// doQueryHedged fires attempt 0 immediately and, every HedgeAfter that
+// passes without a winner, launches another attempt against a server
+// that doesn't already have one in flight (up to MaxConcurrent copies).
+// The first successful response wins and cancels every sibling attempt;
+// errors only surface once all in-flight attempts have failed.
+func (c *HttpQuery) doQueryHedged(ctx context.Context, uri string, body []byte, maxTries int) (*ServerResponse, *errors.Errors) {
+	maxConcurrent := c.MaxConcurrent
+	if maxConcurrent <= 0 || maxConcurrent > maxTries {
+		maxConcurrent = maxTries
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type attemptResult struct {
+		res *ServerResponse
+		err error
+	}
+
+	results := make(chan attemptResult, maxConcurrent)
+
+	var mu sync.Mutex
+	tried := make(map[string]bool)
+
+	launch := func() {
+		mu.Lock()
+		server := c.picker.Pick(tried)
+		tried[server] = true
+		mu.Unlock()
+
+		start := time.Now()
+		res, err := c.doRequest(ctx, server, uri, body)
+		if err != nil {
+			// A losing sibling's ctx gets cancelled the instant another
+			// attempt wins, which surfaces here as a context-cancelled
+			// error. That's not the server's fault, so don't let it
+			// trip the breaker the way a real failure would.
+			if ctx.Err() == nil {
+				c.picker.ReportError(server)
+			}
+		} else {
+			c.picker.ReportSuccess(server, time.Since(start))
+		}
+		results <- attemptResult{res: res, err: err}
+	}
+
+	go launch()
+	inFlight := 1
+
+	timer := time.NewTimer(c.HedgeAfter)
+	defer timer.Stop()
+
+	var e errors.Errors
+	for inFlight > 0 {
+		select {
+		case r := <-results:
+			inFlight--
+			if r.err == nil {
+				cancel()
+				return r.res, nil
+			}
+			e.Add(r.err)
+		case <-timer.C:
+			if inFlight < maxConcurrent {
+				go launch()
+				inFlight++
+			}
+			timer.Reset(c.HedgeAfter)
+		}
+	}
+
+	e.Add(types.ErrMaxTriesExceeded)
+	return nil, &e
+}
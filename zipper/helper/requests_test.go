@@ -0,0 +1,132 @@
+package helper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type fakeLimiter struct{}
+
+func (fakeLimiter) Enter(ctx context.Context, s string) error { return nil }
+func (fakeLimiter) Leave(ctx context.Context, s string)       {}
+
+// orderedPicker always hands out servers in a fixed order, skipping
+// anything already excluded. It gives hedge tests deterministic server
+// selection without depending on CircuitBreakerPicker's randomness.
+type orderedPicker struct {
+	servers []string
+}
+
+func (p *orderedPicker) Pick(exclude map[string]bool) string {
+	for _, s := range p.servers {
+		if !exclude[s] {
+			return s
+		}
+	}
+	return p.servers[0]
+}
+func (p *orderedPicker) ReportSuccess(string, time.Duration) {}
+func (p *orderedPicker) ReportError(string)                  {}
+
+// recordingPicker wraps orderedPicker's deterministic selection and
+// records every ReportError/ReportSuccess call so tests can assert on
+// how a hedge attempt's outcome was reported.
+type recordingPicker struct {
+	orderedPicker
+	mu     sync.Mutex
+	errors []string
+}
+
+func (p *recordingPicker) ReportError(server string) {
+	p.mu.Lock()
+	p.errors = append(p.errors, server)
+	p.mu.Unlock()
+}
+
+func TestDoQueryHedgeDoesNotReportCancelledLoserAsError(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
+	defer fast.Close()
+
+	picker := &recordingPicker{orderedPicker: orderedPicker{servers: []string{slow.URL, fast.URL}}}
+
+	q := NewHttpQuery(zap.NewNop(), "group", []string{slow.URL, fast.URL}, 2, fakeLimiter{}, http.DefaultClient, "")
+	q.SetServerPicker(picker)
+	q.HedgeAfter = 20 * time.Millisecond
+	q.MaxConcurrent = 2
+
+	res, errs := q.DoQuery(context.Background(), "/metrics", nil)
+	if errs != nil {
+		t.Fatalf("DoQuery() error = %v", errs)
+	}
+	if res.Server != fast.URL {
+		t.Fatalf("DoQuery() server = %q, want the fast server %q", res.Server, fast.URL)
+	}
+
+	// Give the cancelled slow attempt's goroutine a moment to report in.
+	time.Sleep(50 * time.Millisecond)
+
+	picker.mu.Lock()
+	defer picker.mu.Unlock()
+	for _, srv := range picker.errors {
+		if srv == slow.URL {
+			t.Fatalf("ReportError(%q) was called for the losing hedge attempt, which only failed because the winner cancelled its context", slow.URL)
+		}
+	}
+}
+
+func TestDoQueryHedgeCancelsLosers(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		case <-time.After(time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
+	defer fast.Close()
+
+	q := NewHttpQuery(zap.NewNop(), "group", []string{slow.URL, fast.URL}, 2, fakeLimiter{}, http.DefaultClient, "")
+	q.SetServerPicker(&orderedPicker{servers: []string{slow.URL, fast.URL}})
+	q.HedgeAfter = 20 * time.Millisecond
+	q.MaxConcurrent = 2
+
+	res, errs := q.DoQuery(context.Background(), "/metrics", nil)
+	if errs != nil {
+		t.Fatalf("DoQuery() error = %v", errs)
+	}
+	if res.Server != fast.URL {
+		t.Fatalf("DoQuery() server = %q, want the fast server %q", res.Server, fast.URL)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow server's request context was never cancelled after the hedge winner returned")
+	}
+}